@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/websocket"
+)
+
+// Client is a downstream connection (a Neovim buffer, a dashboard, ...)
+// registered with a Hub. It receives every message the Hub broadcasts and
+// forwards every message it reads upstream.
+type Client struct {
+	hub  *Hub
+	conn *websocket.Conn
+
+	// send is this client's bounded outbound queue. The hub drops the
+	// client rather than block on a full queue, so a slow consumer can't
+	// stall the rest of the session.
+	send chan []byte
+}
+
+// serveHub upgrades the request to a WebSocket, registers it with hub as a
+// downstream Client, and runs its read/write pumps until the connection or
+// the hub closes it.
+func serveHub(hub *Hub, w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Print("upgrade:", err)
+		return
+	}
+	conn.SetCompressionLevel(*compressLevel)
+	c := &Client{hub: hub, conn: conn, send: make(chan []byte, sendBufSize)}
+	hub.register <- c
+
+	go c.writePump()
+	go c.readPump()
+}
+
+// readPump forwards messages read from the downstream connection upstream,
+// and unregisters the client when the connection closes.
+func (c *Client) readPump() {
+	defer func() {
+		c.hub.unregister <- c
+		c.conn.Close()
+	}()
+	for {
+		_, msg, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		select {
+		case c.hub.upstream <- msg:
+		case <-c.hub.done:
+			// Upstream is gone; stop forwarding instead of blocking forever.
+			return
+		}
+	}
+}
+
+// writePump delivers messages queued on c.send to the downstream connection
+// until the channel is closed by the hub.
+func (c *Client) writePump() {
+	defer c.conn.Close()
+	for msg := range c.send {
+		if err := writeMessage(c.conn, websocket.TextMessage, msg); err != nil {
+			return
+		}
+	}
+	c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+}
+
+// Upstream owns the single connection to the norns matron WebSocket. Every
+// frame it reads is broadcast to all downstream clients; every message
+// queued on hub.upstream by a downstream client is written to it.
+type Upstream struct {
+	hub  *Hub
+	conn *websocket.Conn
+}
+
+// DialUpstream connects to url using dialer, whose NetDialTLSContext (see
+// NewDialer) lets the caller abort a hanging connection attempt by
+// cancelling ctx, and starts the Upstream's pumps in their own goroutines.
+func DialUpstream(ctx context.Context, hub *Hub, url string, dialer *websocket.Dialer) (*Upstream, error) {
+	conn, _, err := dialer.DialContext(ctx, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetCompressionLevel(*compressLevel)
+	u := &Upstream{hub: hub, conn: conn}
+	go u.readPump()
+	go u.writePump()
+	return u, nil
+}
+
+// readPump reads frames from the upstream norns connection and broadcasts
+// them to every downstream client, until the connection drops, at which
+// point it shuts the hub down so clients aren't left forwarding into a
+// connection nothing drains anymore.
+func (u *Upstream) readPump() {
+	defer u.conn.Close()
+	defer u.hub.Shutdown()
+	for {
+		_, msg, err := u.conn.ReadMessage()
+		if err != nil {
+			log.Println("upstream read:", err)
+			return
+		}
+		u.hub.broadcast <- msg
+	}
+}
+
+// writePump writes messages queued on hub.upstream to the upstream norns
+// connection. hub.upstream is never closed (multiple Client.readPumps send
+// to it for as long as they're registered), so this also selects on
+// hub.done -- set by readPump when the upstream connection drops -- to
+// avoid blocking here forever once nothing will ever drain it again.
+func (u *Upstream) writePump() {
+	defer u.hub.Shutdown()
+	for {
+		select {
+		case msg := <-u.hub.upstream:
+			if err := writeMessage(u.conn, websocket.TextMessage, msg); err != nil {
+				log.Println("upstream write:", err)
+				u.conn.Close() // unblock readPump's ReadMessage so it can exit too
+				return
+			}
+		case <-u.hub.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// TestUpstreamWritePumpExitsOnShutdown verifies that Upstream.writePump
+// doesn't block forever ranging over hub.upstream -- which is never closed
+// -- once the hub is shut down because the upstream connection is gone.
+func TestUpstreamWritePumpExitsOnShutdown(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(ws))
+	defer s.Close()
+
+	wsURL := "ws" + s.URL[len("http"):]
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	hub := NewHub(0)
+	go hub.run()
+	u := &Upstream{hub: hub, conn: conn}
+
+	returned := make(chan struct{})
+	go func() {
+		u.writePump()
+		close(returned)
+	}()
+
+	hub.Shutdown()
+
+	select {
+	case <-returned:
+	case <-time.After(time.Second):
+		t.Fatalf("writePump did not return after hub.Shutdown")
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"io"
+	"log"
+	"net/http"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+var cmdLine = flag.String("cmd", "", "command to run for each connection (e.g. \"sh\" or \"matron\"); if set, each WebSocket is bridged to its stdin/stdout instead of echoed")
+
+// killGrace is how long wsCmd waits after SIGTERM before escalating to
+// SIGKILL when closing a connection's child process.
+const killGrace = 2 * time.Second
+
+// wsCmd upgrades the request to a WebSocket and hands the connection off to
+// wsCmdLoop in its own goroutine so the handler returns immediately,
+// releasing net/http's per-request buffers for the life of the bridge.
+func wsCmd(w http.ResponseWriter, r *http.Request) {
+	c, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Print("upgrade:", err)
+		return
+	}
+	c.SetCompressionLevel(*compressLevel)
+	go wsCmdLoop(c)
+}
+
+// wsCmdLoop bridges c to the stdin/stdout of a new instance of *cmdLine,
+// started with os/exec. The child's lifetime is tied to the connection:
+// closing either end tears down the other.
+func wsCmdLoop(c *websocket.Conn) {
+	defer c.Close()
+
+	cmd := exec.Command("sh", "-c", *cmdLine)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		log.Println("StdinPipe:", err)
+		return
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		log.Println("StdoutPipe:", err)
+		return
+	}
+	cmd.Stderr = cmd.Stdout
+
+	if err := cmd.Start(); err != nil {
+		log.Println("start:", err)
+		closeWithCode(c, websocket.CloseInternalServerErr, err.Error())
+		return
+	}
+
+	done := make(chan struct{})
+	var closeDone sync.Once
+	signalDone := func() { closeDone.Do(func() { close(done) }) }
+
+	go cmdReadLoop(c, stdout, signalDone)
+	go cmdWriteLoop(c, stdin, signalDone)
+
+	<-done
+	killCmd(cmd)
+}
+
+// cmdReadLoop copies lines from the child's combined stdout/stderr to the
+// WebSocket as outbound text messages until the pipe breaks, then signals
+// done so the child is reaped even if the browser side is still connected.
+func cmdReadLoop(c *websocket.Conn, stdout io.Reader, done func()) {
+	defer done()
+	s := bufio.NewScanner(stdout)
+	for s.Scan() {
+		if err := writeMessage(c, websocket.TextMessage, s.Bytes()); err != nil {
+			log.Println("WriteMessage:", err)
+			return
+		}
+	}
+	if err := s.Err(); err != nil {
+		log.Println("scan stdout:", err)
+	}
+	closeWithCode(c, websocket.CloseNormalClosure, "process exited")
+}
+
+// cmdWriteLoop forwards inbound WebSocket text messages to the child's
+// stdin, appending a newline to each, then signals done so a browser
+// disconnect kills a REPL child that never exits on stdin EOF (matron,
+// sclang, ...) instead of leaving it running unreaped.
+func cmdWriteLoop(c *websocket.Conn, stdin io.WriteCloser, done func()) {
+	defer done()
+	defer stdin.Close()
+	for {
+		mt, p, err := c.ReadMessage()
+		if err != nil {
+			return
+		}
+		if mt != websocket.TextMessage {
+			continue
+		}
+		if _, err := stdin.Write(append(p, '\n')); err != nil {
+			log.Println("write stdin:", err)
+			return
+		}
+	}
+}
+
+// killCmd sends SIGTERM to cmd's process and escalates to SIGKILL if it
+// hasn't exited within killGrace.
+func killCmd(cmd *exec.Cmd) {
+	if cmd.Process == nil {
+		return
+	}
+	waited := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(waited)
+	}()
+
+	cmd.Process.Signal(syscall.SIGTERM)
+	select {
+	case <-waited:
+	case <-time.After(killGrace):
+		cmd.Process.Kill()
+		<-waited
+	}
+}
+
+// closeWithCode sends a WebSocket close frame with the given code and
+// reason, ignoring write errors since the connection may already be gone.
+func closeWithCode(c *websocket.Conn, code int, text string) {
+	msg := websocket.FormatCloseMessage(code, text)
+	c.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+}
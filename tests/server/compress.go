@@ -0,0 +1,28 @@
+package main
+
+import (
+	"flag"
+
+	"github.com/gorilla/websocket"
+)
+
+var (
+	compress      = flag.Bool("compress", true, "negotiate permessage-deflate compression")
+	compressLevel = flag.Int("compress-level", 1, "deflate compression level to use when -compress is set")
+)
+
+// compressMinSize is the message size, in bytes, above which a message is
+// worth paying deflate's per-message CPU cost for. norns's 128x64 4bpp OLED
+// frames (4096 bytes) comfortably clear this bar; single keyboard/encoder
+// events do not.
+const compressMinSize = 256
+
+// writeMessage writes data to c as a message of type mt, enabling deflate
+// for this one message only when -compress is set and data is large enough
+// to be worth the framing overhead. Every write path that bridges norns
+// traffic (the echo handler, wsCmd, and the Hub's Client/Upstream) goes
+// through this so the -compress/-compress-level flags apply uniformly.
+func writeMessage(c *websocket.Conn, mt int, data []byte) error {
+	c.EnableWriteCompression(*compress && len(data) >= compressMinSize)
+	return c.WriteMessage(mt, data)
+}
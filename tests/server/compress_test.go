@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestCompressionRoundTrip(t *testing.T) {
+	old := upgrader.EnableCompression
+	upgrader.EnableCompression = true
+	defer func() { upgrader.EnableCompression = old }()
+
+	s := httptest.NewServer(http.HandlerFunc(ws))
+	defer s.Close()
+
+	wsURL := "ws" + s.URL[len("http"):]
+	payload := bytes.Repeat([]byte("norns"), 100) // > compressMinSize
+
+	for _, tc := range []struct {
+		name    string
+		dialer  *websocket.Dialer
+		enabled bool
+	}{
+		{"with extension", &websocket.Dialer{EnableCompression: true}, true},
+		{"without extension", &websocket.Dialer{EnableCompression: false}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c, resp, err := tc.dialer.Dial(wsURL, nil)
+			if err != nil {
+				t.Fatalf("dial: %v", err)
+			}
+			defer c.Close()
+
+			negotiated := resp.Header.Get("Sec-WebSocket-Extensions") != ""
+			if negotiated != tc.enabled {
+				t.Fatalf("permessage-deflate negotiated = %v, want %v", negotiated, tc.enabled)
+			}
+
+			if err := c.WriteMessage(websocket.TextMessage, payload); err != nil {
+				t.Fatalf("write: %v", err)
+			}
+			_, got, err := c.ReadMessage()
+			if err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			if !bytes.Equal(got, payload) {
+				t.Fatalf("round-tripped bytes differ: got %d bytes, want %d", len(got), len(payload))
+			}
+		})
+	}
+}
+
+// TestWriteMessageRoundTripsAcrossSizeThreshold verifies that writeMessage -- the shared
+// write path used by the echo handler, wsCmd, and the Hub's Client/Upstream
+// -- round-trips both small (keyboard/encoder-sized) and large (OLED-frame
+// sized) messages correctly regardless of which side of compressMinSize
+// they fall on.
+func TestWriteMessageRoundTripsAcrossSizeThreshold(t *testing.T) {
+	old := upgrader.EnableCompression
+	upgrader.EnableCompression = true
+	defer func() { upgrader.EnableCompression = old }()
+
+	s := httptest.NewServer(http.HandlerFunc(ws))
+	defer s.Close()
+
+	wsURL := "ws" + s.URL[len("http"):]
+	c, _, err := (&websocket.Dialer{EnableCompression: true}).Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer c.Close()
+
+	for _, size := range []int{compressMinSize - 1, compressMinSize + 1} {
+		payload := bytes.Repeat([]byte("x"), size)
+		if err := c.WriteMessage(websocket.TextMessage, payload); err != nil {
+			t.Fatalf("write (size %d): %v", size, err)
+		}
+		_, got, err := c.ReadMessage()
+		if err != nil {
+			t.Fatalf("read (size %d): %v", size, err)
+		}
+		if !bytes.Equal(got, payload) {
+			t.Fatalf("round-tripped bytes differ at size %d: got %d bytes, want %d", size, len(got), len(payload))
+		}
+	}
+}
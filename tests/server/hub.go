@@ -0,0 +1,101 @@
+package main
+
+import (
+	"log"
+	"sync"
+)
+
+// sendBufSize is the number of outbound messages buffered per downstream
+// Client before it is considered too slow and disconnected.
+const sendBufSize = 16
+
+// Hub fans the single upstream norns connection out to every downstream
+// Client (a Neovim buffer, a monitoring dashboard, etc.), and fans inbound
+// messages from those clients back upstream. It is modeled on the gorilla
+// websocket chat example's hub, with a cap on the number of clients it will
+// register.
+type Hub struct {
+	maxClients int
+
+	clients    map[*Client]bool
+	register   chan *Client
+	unregister chan *Client
+	broadcast  chan []byte
+
+	// upstream receives messages forwarded from downstream clients, to be
+	// written to the norns matron connection.
+	upstream chan []byte
+
+	// done is closed by Shutdown when the upstream connection is gone, so
+	// that readPump's blocking send on upstream doesn't wedge forever and
+	// so the hub disconnects every registered client instead of leaving
+	// them as a silent zombie session.
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewHub creates a Hub that will register at most maxClients downstream
+// clients at a time. A maxClients of 0 means unlimited.
+func NewHub(maxClients int) *Hub {
+	return &Hub{
+		maxClients: maxClients,
+		clients:    make(map[*Client]bool),
+		register:   make(chan *Client),
+		unregister: make(chan *Client),
+		broadcast:  make(chan []byte),
+		upstream:   make(chan []byte),
+		done:       make(chan struct{}),
+	}
+}
+
+// Shutdown disconnects every registered client and causes the hub to reject
+// further registrations. It is called once the upstream norns connection is
+// gone, since a hub with no upstream has nothing useful to broadcast or
+// forward. Safe to call more than once or concurrently.
+func (h *Hub) Shutdown() {
+	h.closeOnce.Do(func() { close(h.done) })
+}
+
+// run services the hub's channels and never returns; it is meant to be started
+// in its own goroutine and to run for the lifetime of the process.
+func (h *Hub) run() {
+	closed := false
+	done := h.done
+	for {
+		select {
+		case c := <-h.register:
+			if closed || (h.maxClients > 0 && len(h.clients) >= h.maxClients) {
+				log.Printf("hub: rejecting client, closed=%v max-clients=%d", closed, h.maxClients)
+				close(c.send)
+				continue
+			}
+			h.clients[c] = true
+
+		case c := <-h.unregister:
+			if _, ok := h.clients[c]; ok {
+				delete(h.clients, c)
+				close(c.send)
+			}
+
+		case msg := <-h.broadcast:
+			for c := range h.clients {
+				select {
+				case c.send <- msg:
+				default:
+					// Slow consumer: drop it rather than block the hub.
+					delete(h.clients, c)
+					close(c.send)
+				}
+			}
+
+		case <-done:
+			log.Println("hub: upstream connection closed, disconnecting all clients")
+			closed = true
+			for c := range h.clients {
+				delete(h.clients, c)
+				close(c.send)
+			}
+			done = nil // already handled; don't fire again on the now-closed channel
+		}
+	}
+}
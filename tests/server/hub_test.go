@@ -0,0 +1,92 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestHubMaxClients verifies that a Hub configured with a client cap
+// refuses registrations beyond that cap by closing the client's send
+// channel immediately.
+func TestHubMaxClients(t *testing.T) {
+	hub := NewHub(1)
+	go hub.run()
+
+	a := &Client{hub: hub, send: make(chan []byte, sendBufSize)}
+	hub.register <- a
+
+	b := &Client{hub: hub, send: make(chan []byte, sendBufSize)}
+	hub.register <- b
+
+	// hub.register <- b only rendezvous with the hub's receive; the
+	// close(c.send) that follows in the hub goroutine happens afterward, so
+	// wait for that observable effect instead of a non-blocking select that
+	// can race it.
+	select {
+	case _, ok := <-b.send:
+		if ok {
+			t.Fatalf("expected send channel to be closed for rejected client")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected rejected client's send channel to be closed")
+	}
+}
+
+// TestHubDropsSlowClient verifies that a client whose send buffer is full
+// is disconnected rather than allowed to block broadcast delivery to other
+// clients.
+func TestHubDropsSlowClient(t *testing.T) {
+	hub := NewHub(0)
+	go hub.run()
+
+	slow := &Client{hub: hub, send: make(chan []byte, 1)}
+	fast := &Client{hub: hub, send: make(chan []byte, 1)}
+	hub.register <- slow
+	hub.register <- fast
+
+	for i := 0; i < sendBufSize+2; i++ {
+		hub.broadcast <- []byte("frame")
+		<-fast.send // keep the fast client draining
+	}
+
+	closed := false
+	for !closed {
+		_, ok := <-slow.send
+		closed = !ok
+	}
+}
+
+// TestHubShutdownDisconnectsClients verifies that once the upstream
+// connection is gone and Shutdown is called, every registered client's send
+// channel is closed and new registrations are rejected, instead of the hub
+// silently wedging with clients blocked forwarding into nothing.
+func TestHubShutdownDisconnectsClients(t *testing.T) {
+	hub := NewHub(0)
+	go hub.run()
+
+	a := &Client{hub: hub, send: make(chan []byte, sendBufSize)}
+	hub.register <- a
+
+	hub.Shutdown()
+	hub.Shutdown() // must be safe to call more than once
+
+	select {
+	case _, ok := <-a.send:
+		if ok {
+			t.Fatalf("expected registered client's send channel to be closed on shutdown")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected registered client's send channel to be closed on shutdown")
+	}
+
+	b := &Client{hub: hub, send: make(chan []byte, sendBufSize)}
+	hub.register <- b
+	select {
+	case _, ok := <-b.send:
+		if ok {
+			t.Fatalf("expected client registered after shutdown to be rejected")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected client registered after shutdown to be rejected")
+	}
+}
@@ -1,59 +1,115 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"flag"
 	"io"
 	"log"
 	"net/http"
+	"sync"
 
 	"github.com/gorilla/websocket"
 )
 
 var addr = flag.String("addr", "localhost:8080", "http service address")
 
+// writeBufferPool is shared across all connections so that net/http's
+// per-request buffers can be GC'd as soon as the handler returns instead of
+// being pinned for the life of a long-running bridge.
+var writeBufferPool = &sync.Pool{}
+
 var upgrader = websocket.Upgrader{
 	WriteBufferSize: 253,
+	WriteBufferPool: writeBufferPool,
+	CheckOrigin:     checkOrigin,
 }
 
+// ws upgrades the request to a WebSocket and echoes every message back to
+// the caller. The read/write loop runs in its own goroutine so the HTTP
+// handler can return immediately, releasing net/http's request buffers.
 func ws(w http.ResponseWriter, r *http.Request) {
-	i := 0
 	c, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Print("upgrade:", err)
 		return
 	}
-    buf := make([]byte, upgrader.WriteBufferSize)
+	c.SetCompressionLevel(*compressLevel)
+	go wsLoop(c)
+}
+
+func wsLoop(c *websocket.Conn) {
+	i := 0
 	defer c.Close()
+	var msg bytes.Buffer
 	for {
 		mt, r, err := c.NextReader()
 		if err != nil {
 			log.Println("NextReader:", err)
 			return
 		}
-		w, err := c.NextWriter(mt)
-		if err != nil {
-			log.Println("NextWriter:", err)
-			return
-		}
-        n, err := io.CopyBuffer(w, r, buf)
+		msg.Reset()
+		buf := bufPoolGet()
+		_, err = io.CopyBuffer(&msg, r, buf)
+		bufPoolPut(buf)
 		if err != nil {
 			log.Println("Copy:", err)
 			return
 		}
-		err = w.Close()
-		if err != nil {
-			log.Println("Close:", err)
+
+		if err := writeMessage(c, mt, msg.Bytes()); err != nil {
+			log.Println("Write:", err)
 			return
 		}
 		i++
-		log.Printf("%p %d %d\n", c, i, n)
+		log.Printf("%p %d %d\n", c, i, msg.Len())
 	}
 }
 
+// copyBufPool holds the []byte buffers used by io.CopyBuffer in wsLoop, so
+// that idle connections don't each pin their own copy.
+var copyBufPool = sync.Pool{
+	New: func() any { return make([]byte, upgrader.WriteBufferSize) },
+}
+
+func bufPoolGet() []byte {
+	return copyBufPool.Get().([]byte)
+}
+
+func bufPoolPut(buf []byte) {
+	copyBufPool.Put(buf)
+}
+
+var (
+	upstreamURL = flag.String("upstream", "", "norns matron WebSocket URL to share across clients (e.g. ws://norns.local/matron); if set, downstream connections join a Hub instead of being handled individually")
+	maxClients  = flag.Int("max-clients", 0, "maximum number of simultaneous downstream clients in -upstream mode (0 = unlimited)")
+)
+
 func main() {
 	flag.Parse()
 	log.SetFlags(0)
-	http.HandleFunc("/", ws)
-	log.Printf("listen %s", *addr)
+	upgrader.EnableCompression = *compress
+	switch {
+	case *upstreamURL != "":
+		hub := NewHub(*maxClients)
+		go hub.run()
+		dialer := NewDialer(nil)
+		if _, err := DialUpstream(context.Background(), hub, *upstreamURL, dialer); err != nil {
+			log.Fatal("dial upstream:", err)
+		}
+		http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			serveHub(hub, w, r)
+		})
+	case *cmdLine != "":
+		http.HandleFunc("/", wsCmd)
+	default:
+		http.HandleFunc("/", ws)
+	}
+	http.HandleFunc("/watch", wsWatch)
+	if *tlsCert != "" || *tlsKey != "" {
+		log.Printf("listen wss://%s", *addr)
+		log.Fatal(http.ListenAndServeTLS(*addr, *tlsCert, *tlsKey, nil))
+	}
+	log.Printf("listen ws://%s", *addr)
 	log.Fatal(http.ListenAndServe(*addr, nil))
 }
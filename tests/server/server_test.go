@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// TestIdleConnectionsBoundedHeap opens many idle WebSocket connections and
+// checks that steady-state heap growth stays small, i.e. that net/http's
+// per-request buffers are released once the handler hands off to wsLoop.
+func TestIdleConnectionsBoundedHeap(t *testing.T) {
+	const n = 200
+
+	s := httptest.NewServer(http.HandlerFunc(ws))
+	defer s.Close()
+
+	wsURL := "ws" + s.URL[len("http"):]
+
+	var conns []*websocket.Conn
+	defer func() {
+		for _, c := range conns {
+			c.Close()
+		}
+	}()
+
+	runtime.GC()
+	var before runtime.MemStats
+	runtime.ReadMemStats(&before)
+
+	for i := 0; i < n; i++ {
+		c, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+		if err != nil {
+			t.Fatalf("dial %d: %v", i, err)
+		}
+		conns = append(conns, c)
+	}
+
+	// Give the handlers a moment to hand off to wsLoop and settle.
+	time.Sleep(100 * time.Millisecond)
+	runtime.GC()
+
+	var after runtime.MemStats
+	runtime.ReadMemStats(&after)
+
+	// Per-connection overhead (goroutine state, conn structs, GC slack) in
+	// practice runs several KiB above the theoretical 4KiB/253B buffer
+	// sizes and varies run to run, so this isn't a tight before/after
+	// comparison -- it's a loose sanity bound that steady-state idle
+	// connections aren't leaking heap unboundedly.
+	const maxHeapPerConn = 32 * 1024
+	delta := int64(after.HeapAlloc) - int64(before.HeapAlloc)
+	if want := int64(n * maxHeapPerConn); delta > want {
+		t.Errorf("heap alloc grew by %d bytes after %d idle conns, want <= %d", delta, n, want)
+	}
+}
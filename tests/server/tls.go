@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gorilla/websocket"
+)
+
+var (
+	tlsCert = flag.String("tls-cert", "", "TLS certificate file; if set with -tls-key, serve wss:// instead of ws://")
+	tlsKey  = flag.String("tls-key", "", "TLS private key file")
+)
+
+// originList is a repeatable -allow-origin flag value.
+type originList []string
+
+func (o *originList) String() string { return strings.Join(*o, ",") }
+
+func (o *originList) Set(v string) error {
+	*o = append(*o, v)
+	return nil
+}
+
+var allowOrigin originList
+
+func init() {
+	flag.Var(&allowOrigin, "allow-origin", "allowed Origin header value for WebSocket upgrades; repeatable. If unset, only the request's own host is allowed.")
+}
+
+// checkOrigin implements websocket.Upgrader.CheckOrigin against the
+// -allow-origin allowlist, defaulting to same-host when it's empty.
+func checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if len(allowOrigin) == 0 {
+		return u.Host == r.Host
+	}
+	for _, o := range allowOrigin {
+		if o == origin || o == u.Host {
+			return true
+		}
+	}
+	return false
+}
+
+// NewDialer returns a websocket.Dialer whose TLS connections are
+// established through dialTLSContext, so a caller (norns.nvim's job, or a
+// test) can plug in a context-cancellable dialer -- important so that, say,
+// a Neovim :q can abort a hanging connection attempt to an offline norns
+// instead of waiting out the OS TCP timeout.
+func NewDialer(dialTLSContext func(ctx context.Context, network, addr string) (net.Conn, error)) *websocket.Dialer {
+	return &websocket.Dialer{
+		NetDialTLSContext: dialTLSContext,
+	}
+}
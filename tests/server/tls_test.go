@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckOriginAllowlist(t *testing.T) {
+	defer func() { allowOrigin = nil }()
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+	req.Host = "example.com"
+
+	req.Header.Set("Origin", "http://example.com")
+	if !checkOrigin(req) {
+		t.Errorf("same-host origin should be allowed with empty -allow-origin")
+	}
+
+	req.Header.Set("Origin", "http://evil.example")
+	if checkOrigin(req) {
+		t.Errorf("cross-host origin should be rejected with empty -allow-origin")
+	}
+
+	allowOrigin = originList{"http://evil.example"}
+	if !checkOrigin(req) {
+		t.Errorf("origin present in -allow-origin should be allowed")
+	}
+}
+
+// TestDialUpstreamContextCancel verifies that cancelling the context passed
+// to DialUpstream aborts a hanging TLS dial immediately, instead of waiting
+// out the OS TCP connect timeout -- the behavior this request exists for,
+// so a Neovim :q can abort a connection attempt to an offline norns.
+func TestDialUpstreamContextCancel(t *testing.T) {
+	blockUntilCanceled := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	dialer := NewDialer(blockUntilCanceled)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+
+	start := time.Now()
+	_, err := DialUpstream(ctx, NewHub(0), "wss://norns.invalid:443", dialer)
+	elapsed := time.Since(start)
+
+	if err == nil {
+		t.Fatalf("expected dial to fail once ctx is canceled")
+	}
+	if !errors.Is(err, context.Canceled) && !strings.Contains(err.Error(), "context canceled") {
+		t.Errorf("err = %v, want it to reflect context cancellation", err)
+	}
+	if elapsed > 2*time.Second {
+		t.Errorf("dial took %v after cancellation, want it to abort promptly instead of hitting an OS timeout", elapsed)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/gorilla/websocket"
+)
+
+var watchDebounce = flag.Duration("watch-debounce", 200*time.Millisecond, "coalesce filewatch events for a path within this window into a single message")
+
+const (
+	pongWait   = 60 * time.Second
+	pingPeriod = pongWait * 9 / 10
+	writeWait  = 10 * time.Second
+)
+
+// watchEvent is the JSON message emitted over the WebSocket each time a
+// watched path changes, coalesced to one per debounce window.
+type watchEvent struct {
+	Path  string `json:"path"`
+	Op    string `json:"op"`
+	Mtime int64  `json:"mtime"`
+}
+
+// wsWatch upgrades the request to a WebSocket and streams debounced
+// filewatch events for the path named by the "path" query parameter, so
+// that norns.nvim can trigger a script reload on save without polling.
+func wsWatch(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		http.Error(w, "missing path query parameter", http.StatusBadRequest)
+		return
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Println("fsnotify:", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := watcher.Add(path); err != nil {
+		log.Println("watch add:", err)
+		watcher.Close()
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	c, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Print("upgrade:", err)
+		watcher.Close()
+		return
+	}
+
+	go watchLoop(c, watcher)
+}
+
+// watchLoop coalesces fsnotify events arriving within *watchDebounce into a
+// single outbound message per path, and keeps the connection alive with a
+// write-deadline-based ping/pong until the watcher or the connection fails.
+func watchLoop(c *websocket.Conn, watcher *fsnotify.Watcher) {
+	defer c.Close()
+	defer watcher.Close()
+
+	c.SetReadDeadline(time.Now().Add(pongWait))
+	c.SetPongHandler(func(string) error {
+		c.SetReadDeadline(time.Now().Add(pongWait))
+		return nil
+	})
+	go watchReadPump(c)
+
+	ping := time.NewTicker(pingPeriod)
+	defer ping.Stop()
+
+	pending := make(map[string]fsnotify.Event)
+	var debounce *time.Timer
+
+	for {
+		var debounceC <-chan time.Time
+		if debounce != nil {
+			debounceC = debounce.C
+		}
+
+		select {
+		case ev, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			pending[ev.Name] = ev
+			if debounce == nil {
+				debounce = time.NewTimer(*watchDebounce)
+			} else {
+				debounce.Reset(*watchDebounce)
+			}
+
+		case <-debounceC:
+			for name, ev := range pending {
+				if err := sendWatchEvent(c, &ev); err != nil {
+					log.Println("write watch event:", err)
+					return
+				}
+				delete(pending, name)
+			}
+			debounce = nil
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Println("watch error:", err)
+
+		case <-ping.C:
+			c.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// watchReadPump discards any inbound messages but keeps reading so pong
+// control frames update the read deadline; it returns once the connection
+// is closed by the peer or by watchLoop.
+func watchReadPump(c *websocket.Conn) {
+	for {
+		if _, _, err := c.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+func sendWatchEvent(c *websocket.Conn, ev *fsnotify.Event) error {
+	info, err := os.Stat(ev.Name)
+	var mtime int64
+	if err == nil {
+		mtime = info.ModTime().Unix()
+	}
+	b, err := json.Marshal(watchEvent{
+		Path:  ev.Name,
+		Op:    ev.Op.String(),
+		Mtime: mtime,
+	})
+	if err != nil {
+		return err
+	}
+	c.SetWriteDeadline(time.Now().Add(writeWait))
+	return c.WriteMessage(websocket.TextMessage, b)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestWatchDebouncesBursts(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "script.lua")
+	if err := os.WriteFile(path, []byte("-- v0"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	*watchDebounce = 50 * time.Millisecond
+
+	s := httptest.NewServer(http.HandlerFunc(wsWatch))
+	defer s.Close()
+
+	wsURL := "ws" + s.URL[len("http"):] + "?" + url.Values{"path": {path}}.Encode()
+	c, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer c.Close()
+
+	// A burst of writes within one debounce window should collapse to a
+	// single event, the way a single :w in Neovim might still trigger
+	// several underlying filesystem notifications.
+	for i := 0; i < 5; i++ {
+		if err := os.WriteFile(path, []byte("-- v"+string(rune('1'+i))), 0644); err != nil {
+			t.Fatalf("write %d: %v", i, err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	c.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, msg, err := c.ReadMessage()
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	var ev watchEvent
+	if err := json.Unmarshal(msg, &ev); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if ev.Path != path {
+		t.Errorf("path = %q, want %q", ev.Path, path)
+	}
+
+	// No second event should follow for this burst.
+	c.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	if _, _, err := c.ReadMessage(); err == nil {
+		t.Fatalf("expected no further event after debounced burst")
+	}
+}